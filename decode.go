@@ -7,27 +7,57 @@ package goyaml
 import "C"
 
 import (
+	"encoding"
+	"encoding/base64"
+	"io"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 	"unsafe"
 )
 
+type Kind int
+
+const (
+	DocumentNode Kind = 1 << iota
+	MappingNode
+	SequenceNode
+	ScalarNode
+	AliasNode
+)
+
+// Style describes how a scalar or collection was written in the source
+// document — plain, quoted, literal/folded, or flow — so that tools
+// building on the Node tree can preserve formatting when editing and
+// re-emitting a document.
+type Style int
+
 const (
-	documentNode = 1 << iota
-	mappingNode
-	sequenceNode
-	scalarNode
-	aliasNode
+	PlainStyle Style = iota
+	SingleQuotedStyle
+	DoubleQuotedStyle
+	LiteralStyle
+	FoldedStyle
+	FlowStyle
 )
 
-type node struct {
-	kind         int
-	line, column int
-	tag          string
-	value        string
+// A Node is a single element of a parsed YAML document — a scalar, an
+// alias, or a mapping/sequence/document collecting other Nodes as
+// Children. It's the same tree Unmarshal walks internally; Decoder.Decode
+// and Node.Decode expose it directly for tools that need to inspect or
+// round-trip a document losslessly instead of decoding straight into a
+// Go value.
+type Node struct {
+	Kind         Kind
+	Line, Column int
+	Tag          string
+	Value        string
+	Anchor       string
+	Style        Style
+	Children     []*Node
 	implicit     bool
-	children     []*node
-	anchors      map[string]*node
+	anchors      map[string]*Node
 }
 
 func stry(s *C.yaml_char_t) string {
@@ -38,9 +68,11 @@ func stry(s *C.yaml_char_t) string {
 // Parser, produces a node tree out of a libyaml event stream.
 
 type parser struct {
-	parser C.yaml_parser_t
-	event  C.yaml_event_t
-	doc    *node
+	parser  C.yaml_parser_t
+	event   C.yaml_event_t
+	doc     *Node
+	reader  io.Reader
+	readErr error
 }
 
 func newParser(b []byte) *parser {
@@ -58,13 +90,45 @@ func newParser(b []byte) *parser {
 	input := (*C.uchar)(unsafe.Pointer(&b[0]))
 	C.yaml_parser_set_input_string(&p.parser, input, (C.size_t)(len(b)))
 
+	p.init()
+	return &p
+}
+
+// newParserFromReader is like newParser, but pulls bytes from r on demand
+// via a libyaml read handler instead of requiring the whole document set
+// to be buffered up front. This is what lets NewDecoder stream arbitrarily
+// large, possibly multi-document, input.
+func newParserFromReader(r io.Reader) *parser {
+	p := parser{reader: r}
+	if C.yaml_parser_initialize(&p.parser) == 0 {
+		panic("Failed to initialize YAML emitter")
+	}
+	C.yaml_parser_set_input(&p.parser, C.yaml_read_handler_t(C.go_read_handler), unsafe.Pointer(&p))
+	p.init()
+	return &p
+}
+
+// init advances past the stream start event shared by both constructors.
+func (p *parser) init() {
 	p.skip()
 	if p.event._type != C.YAML_STREAM_START_EVENT {
 		panic("Expected stream start event, got " +
 			strconv.Itoa(int(p.event._type)))
 	}
 	p.skip()
-	return &p
+}
+
+//export go_read_handler
+func go_read_handler(data unsafe.Pointer, buffer *C.uchar, size C.size_t, sizeRead *C.size_t) C.int {
+	p := (*parser)(data)
+	out := (*[1 << 30]byte)(unsafe.Pointer(buffer))[:int(size):int(size)]
+	n, err := p.reader.Read(out)
+	*sizeRead = C.size_t(n)
+	if err != nil && err != io.EOF {
+		p.readErr = err
+		return 0
+	}
+	return 1
 }
 
 func (p *parser) destroy() {
@@ -87,6 +151,9 @@ func (p *parser) skip() {
 }
 
 func (p *parser) fail() {
+	if p.readErr != nil {
+		panic(p.readErr)
+	}
 	var where string
 	var line int
 	if p.parser.problem_mark.line != 0 {
@@ -106,13 +173,31 @@ func (p *parser) fail() {
 	panic(where + msg)
 }
 
-func (p *parser) anchor(n *node, anchor *C.yaml_char_t) {
+func (p *parser) anchor(n *Node, anchor *C.yaml_char_t) {
 	if anchor != nil {
-		p.doc.anchors[stry(anchor)] = n
+		n.Anchor = stry(anchor)
+		p.doc.anchors[n.Anchor] = n
 	}
 }
 
-func (p *parser) parse() *node {
+// scalarStyle translates a libyaml scalar style into the exported Style
+// enum, defaulting unrecognized/"any" styles to PlainStyle.
+func scalarStyle(s C.yaml_scalar_style_t) Style {
+	switch s {
+	case C.YAML_SINGLE_QUOTED_SCALAR_STYLE:
+		return SingleQuotedStyle
+	case C.YAML_DOUBLE_QUOTED_SCALAR_STYLE:
+		return DoubleQuotedStyle
+	case C.YAML_LITERAL_SCALAR_STYLE:
+		return LiteralStyle
+	case C.YAML_FOLDED_SCALAR_STYLE:
+		return FoldedStyle
+	default:
+		return PlainStyle
+	}
+}
+
+func (p *parser) parse() *Node {
 	switch p.event._type {
 	case C.YAML_SCALAR_EVENT:
 		return p.scalar()
@@ -134,18 +219,34 @@ func (p *parser) parse() *node {
 	panic("Unreachable")
 }
 
-func (p *parser) node(kind int) *node {
-	return &node{kind: kind,
-		line:   int(C.int(p.event.start_mark.line)),
-		column: int(C.int(p.event.start_mark.column))}
+func (p *parser) newNode(kind Kind) *Node {
+	return &Node{Kind: kind,
+		Line:   int(C.int(p.event.start_mark.line)),
+		Column: int(C.int(p.event.start_mark.column))}
 }
 
-func (p *parser) document() *node {
-	n := p.node(documentNode)
-	n.anchors = make(map[string]*node)
+// nextDocument parses and returns the next document in the stream, so a
+// single parser can be driven one document at a time instead of assuming
+// there's exactly one. It reports ok=false once the stream is exhausted
+// (YAML_STREAM_END_EVENT), which is how NewDecoder's Decoder surfaces
+// io.EOF for "---"-separated multi-document input.
+func (p *parser) nextDocument() (n *Node, ok bool) {
+	if p.event._type == C.YAML_STREAM_END_EVENT {
+		return nil, false
+	}
+	if p.event._type != C.YAML_DOCUMENT_START_EVENT {
+		panic("Expected document start event, got " +
+			strconv.Itoa(int(p.event._type)))
+	}
+	return p.document(), true
+}
+
+func (p *parser) document() *Node {
+	n := p.newNode(DocumentNode)
+	n.anchors = make(map[string]*Node)
 	p.doc = n
 	p.skip()
-	n.children = append(n.children, p.parse())
+	n.Children = append(n.Children, p.parse())
 	if p.event._type != C.YAML_DOCUMENT_END_EVENT {
 		panic("Expected end of document event but got " +
 			strconv.Itoa(int(p.event._type)))
@@ -154,42 +255,51 @@ func (p *parser) document() *node {
 	return n
 }
 
-func (p *parser) alias() *node {
+func (p *parser) alias() *Node {
 	alias := C.event_alias(&p.event)
-	n := p.node(aliasNode)
-	n.value = stry(alias.anchor)
+	n := p.newNode(AliasNode)
+	n.Value = stry(alias.anchor)
 	p.skip()
 	return n
 }
 
-func (p *parser) scalar() *node {
+func (p *parser) scalar() *Node {
 	scalar := C.event_scalar(&p.event)
-	n := p.node(scalarNode)
-	n.value = stry(scalar.value)
-	n.tag = stry(scalar.tag)
+	n := p.newNode(ScalarNode)
+	n.Value = stry(scalar.value)
+	n.Tag = stry(scalar.tag)
 	n.implicit = (scalar.plain_implicit != 0)
+	n.Style = scalarStyle(scalar.style)
 	p.anchor(n, scalar.anchor)
 	p.skip()
 	return n
 }
 
-func (p *parser) sequence() *node {
-	n := p.node(sequenceNode)
-	p.anchor(n, C.event_sequence_start(&p.event).anchor)
+func (p *parser) sequence() *Node {
+	event := C.event_sequence_start(&p.event)
+	n := p.newNode(SequenceNode)
+	if event.style == C.YAML_FLOW_SEQUENCE_STYLE {
+		n.Style = FlowStyle
+	}
+	p.anchor(n, event.anchor)
 	p.skip()
 	for p.event._type != C.YAML_SEQUENCE_END_EVENT {
-		n.children = append(n.children, p.parse())
+		n.Children = append(n.Children, p.parse())
 	}
 	p.skip()
 	return n
 }
 
-func (p *parser) mapping() *node {
-	n := p.node(mappingNode)
-	p.anchor(n, C.event_mapping_start(&p.event).anchor)
+func (p *parser) mapping() *Node {
+	event := C.event_mapping_start(&p.event)
+	n := p.newNode(MappingNode)
+	if event.style == C.YAML_FLOW_MAPPING_STYLE {
+		n.Style = FlowStyle
+	}
+	p.anchor(n, event.anchor)
 	p.skip()
 	for p.event._type != C.YAML_MAPPING_END_EVENT {
-		n.children = append(n.children, p.parse(), p.parse())
+		n.Children = append(n.Children, p.parse(), p.parse())
 	}
 	p.skip()
 	return n
@@ -199,8 +309,10 @@ func (p *parser) mapping() *node {
 // Decoder, unmarshals a node into a provided value.
 
 type decoder struct {
-	doc     *node
+	doc     *Node
 	aliases map[string]bool
+	terrors []string
+	strict  bool
 }
 
 func newDecoder() *decoder {
@@ -209,6 +321,23 @@ func newDecoder() *decoder {
 	return d
 }
 
+// terror records a per-field type error rather than failing the whole
+// document, so that a document with one bad field (e.g. v: 128 into an
+// int8) still decodes everything else. Decoder.Decode and Node.Decode
+// surface the accumulated errors as a *TypeError once decoding finishes;
+// Unmarshal does not report them.
+func (d *decoder) terror(n *Node, tag string, out reflect.Value) {
+	if n.Tag != "" {
+		tag = n.Tag
+	}
+	value := n.Value
+	if tag != "!!str" && len(value) > 10 {
+		value = value[:7] + "..."
+	}
+	d.terrors = append(d.terrors, strconv.Itoa(n.Line+1)+":"+strconv.Itoa(n.Column+1)+
+		": cannot unmarshal "+tag+" `"+value+"` into "+out.Type().String())
+}
+
 // d.setter deals with setters and pointer dereferencing and initialization.
 //
 // It's a slightly convoluted case to handle properly:
@@ -253,61 +382,105 @@ func (d *decoder) setter(tag string, out *reflect.Value, good *bool) (set func()
 	return nil
 }
 
-func (d *decoder) unmarshal(n *node, out reflect.Value) (good bool) {
-	switch n.kind {
-	case documentNode:
+func (d *decoder) unmarshal(n *Node, out reflect.Value) (good bool) {
+	switch n.Kind {
+	case DocumentNode:
 		good = d.document(n, out)
-	case scalarNode:
+	case ScalarNode:
 		good = d.scalar(n, out)
-	case aliasNode:
+	case AliasNode:
 		good = d.alias(n, out)
-	case mappingNode:
+	case MappingNode:
 		good = d.mapping(n, out)
-	case sequenceNode:
+	case SequenceNode:
 		good = d.sequence(n, out)
 	default:
-		panic("Internal error: unknown node kind: " + strconv.Itoa(n.kind))
+		panic("Internal error: unknown node kind: " + strconv.Itoa(int(n.Kind)))
 	}
 	return
 }
 
-func (d *decoder) document(n *node, out reflect.Value) (good bool) {
-	if len(n.children) == 1 {
+func (d *decoder) document(n *Node, out reflect.Value) (good bool) {
+	if len(n.Children) == 1 {
 		d.doc = n
-		d.unmarshal(n.children[0], out)
+		d.unmarshal(n.Children[0], out)
 		return true
 	}
 	return false
 }
 
-func (d *decoder) alias(n *node, out reflect.Value) (good bool) {
-	an, ok := d.doc.anchors[n.value]
+func (d *decoder) alias(n *Node, out reflect.Value) (good bool) {
+	an, ok := d.doc.anchors[n.Value]
 	if !ok {
-		panic("Unknown anchor '" + n.value + "' referenced")
+		panic("Unknown anchor '" + n.Value + "' referenced")
 	}
-	if d.aliases[n.value] {
-		panic("Anchor '" + n.value + "' value contains itself")
+	if d.aliases[n.Value] {
+		panic("Anchor '" + n.Value + "' value contains itself")
 	}
-	d.aliases[n.value] = true
+	d.aliases[n.Value] = true
 	good = d.unmarshal(an, out)
-	delete(d.aliases, n.value)
+	delete(d.aliases, n.Value)
 	return good
 }
 
-func (d *decoder) scalar(n *node, out reflect.Value) (good bool) {
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (d *decoder) scalar(n *Node, out reflect.Value) (good bool) {
 	var tag string
 	var resolved interface{}
-	if n.tag == "" && !n.implicit {
-		resolved = n.value
+	if n.Tag == "" && !n.implicit {
+		tag = "!!str"
+		resolved = n.Value
 	} else {
-		tag, resolved = resolve(n.tag, n.value)
+		tag, resolved = resolve(n.Tag, n.Value)
 		if set := d.setter(tag, &out, &good); set != nil {
 			defer set()
 		}
 	}
+	// Checked ahead of textUnmarshaler: time.Time itself implements
+	// encoding.TextUnmarshaler, but only for strict RFC 3339, which would
+	// otherwise shadow the looser timestampFormats parseTimestamp accepts.
+	switch {
+	case out.Kind() != reflect.Interface && out.Type() == timeType:
+		t, err := parseTimestamp(n.Value)
+		if err != nil {
+			d.terror(n, tag, out)
+			return false
+		}
+		out.Set(reflect.ValueOf(t))
+		return true
+	case out.Kind() != reflect.Interface && out.Type() == durationType:
+		dur, err := time.ParseDuration(n.Value)
+		if err != nil {
+			d.terror(n, tag, out)
+			return false
+		}
+		out.SetInt(int64(dur))
+		return true
+	case out.Kind() == reflect.Interface && tag == "!!timestamp":
+		if t, err := parseTimestamp(n.Value); err == nil {
+			out.Set(reflect.ValueOf(t))
+			return true
+		}
+	}
+	if u, ok := textUnmarshaler(out); ok {
+		if err := u.UnmarshalText([]byte(n.Value)); err != nil {
+			d.terror(n, tag, out)
+			return false
+		}
+		return true
+	}
+	if tag == "!!binary" {
+		if !d.binary(n, out) {
+			d.terror(n, tag, out)
+			return false
+		}
+		return true
+	}
 	switch out.Kind() {
 	case reflect.String:
-		out.SetString(n.value)
+		out.SetString(n.Value)
 		good = true
 	case reflect.Interface:
 		if resolved == nil {
@@ -329,31 +502,43 @@ func (d *decoder) scalar(n *node, out reflect.Value) (good bool) {
 				good = true
 			}
 		}
+		if !good {
+			d.terror(n, tag, out)
+		}
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		switch resolved := resolved.(type) {
 		case int:
-			if resolved >= 0 {
+			if resolved >= 0 && !out.OverflowUint(uint64(resolved)) {
 				out.SetUint(uint64(resolved))
 				good = true
 			}
 		case int64:
-			if resolved >= 0 {
+			if resolved >= 0 && !out.OverflowUint(uint64(resolved)) {
 				out.SetUint(uint64(resolved))
 				good = true
 			}
 		}
+		if !good {
+			d.terror(n, tag, out)
+		}
 	case reflect.Bool:
 		switch resolved := resolved.(type) {
 		case bool:
 			out.SetBool(resolved)
 			good = true
 		}
+		if !good {
+			d.terror(n, tag, out)
+		}
 	case reflect.Float32, reflect.Float64:
 		switch resolved := resolved.(type) {
 		case float64:
 			out.SetFloat(resolved)
 			good = true
 		}
+		if !good {
+			d.terror(n, tag, out)
+		}
 	case reflect.Ptr:
 		switch resolved.(type) {
 		case nil:
@@ -364,6 +549,70 @@ func (d *decoder) scalar(n *node, out reflect.Value) (good bool) {
 	return good
 }
 
+// timestampFormats are tried in order against scalars tagged (explicitly
+// or implicitly) as !!timestamp, matching the layouts allowed by the
+// YAML 1.1 timestamp schema: canonical ISO 8601/RFC 3339 with an
+// optional fractional second and "Z" or numeric zone, the more lenient
+// space-separated variant, and a bare date.
+var timestampFormats = []string{
+	time.RFC3339Nano,
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2t15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999",
+	"2006-1-2",
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	var firstErr error
+	for _, format := range timestampFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t, nil
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+// textUnmarshaler reports whether out, or a pointer to out, implements
+// encoding.TextUnmarshaler, returning it as such when it does. This lets
+// scalars decode directly into types like net.IP or big.Int without
+// requiring a bespoke SetYAML implementation.
+func textUnmarshaler(out reflect.Value) (u encoding.TextUnmarshaler, ok bool) {
+	if out.CanAddr() {
+		if u, ok = out.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u, true
+		}
+	}
+	if out.CanInterface() {
+		u, ok = out.Interface().(encoding.TextUnmarshaler)
+	}
+	return u, ok
+}
+
+// binary decodes a scalar tagged !!binary, the YAML schema for arbitrary
+// byte data, and assigns the result either to a BinaryUnmarshaler or
+// directly to a []byte destination.
+func (d *decoder) binary(n *Node, out reflect.Value) (good bool) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.Value), ""))
+	if err != nil {
+		return false
+	}
+	if out.CanAddr() {
+		if u, ok := out.Addr().Interface().(encoding.BinaryUnmarshaler); ok {
+			if err := u.UnmarshalBinary(data); err != nil {
+				return false
+			}
+			return true
+		}
+	}
+	if out.Kind() == reflect.Slice && out.Type().Elem().Kind() == reflect.Uint8 {
+		out.SetBytes(data)
+		return true
+	}
+	return false
+}
+
 func settableValueOf(i interface{}) reflect.Value {
 	v := reflect.ValueOf(i)
 	sv := reflect.New(v.Type()).Elem()
@@ -371,7 +620,7 @@ func settableValueOf(i interface{}) reflect.Value {
 	return sv
 }
 
-func (d *decoder) sequence(n *node, out reflect.Value) (good bool) {
+func (d *decoder) sequence(n *Node, out reflect.Value) (good bool) {
 	if set := d.setter("!!seq", &out, &good); set != nil {
 		defer set()
 	}
@@ -387,17 +636,20 @@ func (d *decoder) sequence(n *node, out reflect.Value) (good bool) {
 	}
 	et := out.Type().Elem()
 
-	l := len(n.children)
+	l := len(n.Children)
 	for i := 0; i < l; i++ {
 		e := reflect.New(et).Elem()
-		if ok := d.unmarshal(n.children[i], e); ok {
+		if ok := d.unmarshal(n.Children[i], e); ok {
 			out.Set(reflect.Append(out, e))
 		}
 	}
 	return true
 }
 
-func (d *decoder) mapping(n *node, out reflect.Value) (good bool) {
+func (d *decoder) mapping(n *Node, out reflect.Value) (good bool) {
+	if d.strict {
+		d.checkDuplicateKeys(n)
+	}
 	if set := d.setter("!!map", &out, &good); set != nil {
 		defer set()
 	}
@@ -422,12 +674,13 @@ func (d *decoder) mapping(n *node, out reflect.Value) (good bool) {
 	if out.IsNil() {
 		out.Set(reflect.MakeMap(outt))
 	}
-	l := len(n.children)
+	children := d.expandMerge(n)
+	l := len(children)
 	for i := 0; i < l; i += 2 {
 		k := reflect.New(kt).Elem()
-		if d.unmarshal(n.children[i], k) {
+		if d.unmarshal(children[i], k) {
 			e := reflect.New(et).Elem()
-			if d.unmarshal(n.children[i+1], e) {
+			if d.unmarshal(children[i+1], e) {
 				out.SetMapIndex(k, e)
 			}
 		}
@@ -435,21 +688,114 @@ func (d *decoder) mapping(n *node, out reflect.Value) (good bool) {
 	return true
 }
 
-func (d *decoder) mappingStruct(n *node, out reflect.Value) (good bool) {
+func (d *decoder) mappingStruct(n *Node, out reflect.Value) (good bool) {
 	fields, err := getStructFields(out.Type())
 	if err != nil {
 		panic(err)
 	}
 	name := settableValueOf("")
 	fieldsMap := fields.Map
-	l := len(n.children)
+	children := d.expandMerge(n)
+	l := len(children)
 	for i := 0; i < l; i += 2 {
-		if !d.unmarshal(n.children[i], name) {
+		k := children[i]
+		if !d.unmarshal(k, name) {
 			continue
 		}
 		if info, ok := fieldsMap[name.String()]; ok {
-			d.unmarshal(n.children[i+1], out.Field(info.Num))
+			d.unmarshal(children[i+1], out.Field(info.Num))
+		} else if d.strict {
+			d.terrors = append(d.terrors, strconv.Itoa(k.Line+1)+":"+strconv.Itoa(k.Column+1)+
+				": unknown field \""+name.String()+"\" in "+out.Type().String())
 		}
 	}
 	return true
 }
+
+// checkDuplicateKeys records a terror for every scalar key that
+// appears more than once directly in n, the mapping node itself rather
+// than anything brought in by a "<<" merge. It's only consulted in
+// KnownFields mode, where a repeated key such as a copy/paste mistake is
+// a config bug rather than something to silently overwrite.
+func (d *decoder) checkDuplicateKeys(n *Node) {
+	seen := make(map[string]bool)
+	l := len(n.Children)
+	for i := 0; i < l; i += 2 {
+		k := n.Children[i]
+		if k.Kind != ScalarNode || isMergeKey(k) {
+			continue
+		}
+		if seen[k.Value] {
+			d.terrors = append(d.terrors, strconv.Itoa(k.Line+1)+":"+strconv.Itoa(k.Column+1)+
+				": duplicate key \""+k.Value+"\" in mapping")
+			continue
+		}
+		seen[k.Value] = true
+	}
+}
+
+// isMergeKey reports whether n is a mapping key using the YAML 1.1 merge
+// key convention: the plain scalar "<<", optionally tagged !!merge.
+func isMergeKey(n *Node) bool {
+	return n.Kind == ScalarNode && n.implicit && n.Value == "<<" &&
+		(n.Tag == "" || n.Tag == "!!merge")
+}
+
+// expandMerge returns n's flat [key0, value0, key1, value1, ...] children
+// with any "<<" merge keys replaced by the key/value pairs of the
+// mapping(s) they reference. Keys written explicitly in n always win over
+// merged-in keys, and when a merge value is a sequence of mappings,
+// earlier entries win over later ones.
+func (d *decoder) expandMerge(n *Node) []*Node {
+	var merged, explicit []*Node
+	l := len(n.Children)
+	for i := 0; i < l; i += 2 {
+		k, v := n.Children[i], n.Children[i+1]
+		if !isMergeKey(k) {
+			explicit = append(explicit, k, v)
+			continue
+		}
+		sources := d.mergeSources(v)
+		// Sources are in decreasing priority order; append them back to
+		// front so that, as plain map/field assignment is last-write-wins,
+		// the highest priority source is applied last. Each source is
+		// itself expanded recursively, so a merge source that contains its
+		// own << key is flattened rather than leaking a literal "<<" field.
+		for j := len(sources) - 1; j >= 0; j-- {
+			merged = append(merged, d.expandMerge(sources[j])...)
+		}
+	}
+	if merged == nil {
+		return explicit
+	}
+	return append(merged, explicit...)
+}
+
+// mergeSources resolves a merge key's value into the ordered list of
+// mapping nodes it refers to, following aliases through d.doc.anchors and
+// guarding against cycles with d.aliases, the same set used by d.alias.
+func (d *decoder) mergeSources(n *Node) []*Node {
+	switch n.Kind {
+	case MappingNode:
+		return []*Node{n}
+	case SequenceNode:
+		var sources []*Node
+		for _, c := range n.Children {
+			sources = append(sources, d.mergeSources(c)...)
+		}
+		return sources
+	case AliasNode:
+		an, ok := d.doc.anchors[n.Value]
+		if !ok {
+			panic("Unknown anchor '" + n.Value + "' referenced")
+		}
+		if d.aliases[n.Value] {
+			panic("Anchor '" + n.Value + "' value contains itself")
+		}
+		d.aliases[n.Value] = true
+		sources := d.mergeSources(an)
+		delete(d.aliases, n.Value)
+		return sources
+	}
+	panic("map merge requires map or sequence of maps as the value")
+}
@@ -1,10 +1,13 @@
 package goyaml_test
 
 import (
+	"io"
 	. "launchpad.net/gocheck"
 	"launchpad.net/goyaml"
 	"math"
 	"reflect"
+	"strings"
+	"time"
 )
 
 var unmarshalIntTest = 123
@@ -234,3 +237,168 @@ func (s *S) TestUnmarshalWithFalseSetterIgnoresValue(c *C) {
 	c.Assert(m["abc"].value, Equals, 1)
 	c.Assert(m["ghi"].value, Equals, 3)
 }
+
+func (s *S) TestDecoder(c *C) {
+	dec := goyaml.NewDecoder(strings.NewReader("v: hi\n"))
+	var m map[string]string
+	err := dec.Decode(&m)
+	c.Assert(err, IsNil)
+	c.Assert(m, DeepEquals, map[string]string{"v": "hi"})
+}
+
+func (s *S) TestDecoderMultidoc(c *C) {
+	dec := goyaml.NewDecoder(strings.NewReader("a: 1\n---\na: 2\n"))
+	var docs []map[string]int
+	for {
+		var m map[string]int
+		err := dec.Decode(&m)
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		docs = append(docs, m)
+	}
+	c.Assert(docs, DeepEquals, []map[string]int{
+		{"a": 1},
+		{"a": 2},
+	})
+}
+
+func (s *S) TestUnmarshalTimestamp(c *C) {
+	var m struct {
+		V time.Time
+	}
+	err := goyaml.Unmarshal([]byte("v: 2015-02-24T18:19:39.12Z"), &m)
+	c.Assert(err, IsNil)
+	c.Assert(m.V.Equal(time.Date(2015, 2, 24, 18, 19, 39, 120000000, time.UTC)), Equals, true)
+}
+
+func (s *S) TestUnmarshalDuration(c *C) {
+	var m struct {
+		V time.Duration
+	}
+	err := goyaml.Unmarshal([]byte("v: 5m30s"), &m)
+	c.Assert(err, IsNil)
+	c.Assert(m.V, Equals, 5*time.Minute+30*time.Second)
+}
+
+type textUnmarshalerValue struct {
+	s string
+}
+
+func (v *textUnmarshalerValue) UnmarshalText(text []byte) error {
+	v.s = "text:" + string(text)
+	return nil
+}
+
+func (s *S) TestUnmarshalTextUnmarshaler(c *C) {
+	var m struct {
+		V textUnmarshalerValue
+	}
+	err := goyaml.Unmarshal([]byte("v: hello"), &m)
+	c.Assert(err, IsNil)
+	c.Assert(m.V.s, Equals, "text:hello")
+}
+
+func (s *S) TestUnmarshalBinary(c *C) {
+	var m struct {
+		V []byte
+	}
+	err := goyaml.Unmarshal([]byte("v: !!binary aGVsbG8=\n"), &m)
+	c.Assert(err, IsNil)
+	c.Assert(string(m.V), Equals, "hello")
+}
+
+func (s *S) TestMerge(c *C) {
+	var m1 map[interface{}]interface{}
+	data := "center: &CENTER {x: 1, y: 2}\nleft: &LEFT {x: 0, y: 2}\nbig: &BIG {r: 10}\n" +
+		"small: &SMALL {r: 1}\n" +
+		"explicit:\n  <<: *CENTER\n  r: 10\n" +
+		"shallow:\n  <<: *CENTER\n" +
+		"noclobber:\n  <<: *CENTER\n  x: 1\n"
+	err := goyaml.Unmarshal([]byte(data), &m1)
+	c.Assert(err, IsNil)
+
+	explicit := m1["explicit"].(map[interface{}]interface{})
+	c.Assert(explicit["x"], Equals, 1)
+	c.Assert(explicit["y"], Equals, 2)
+	c.Assert(explicit["r"], Equals, 10)
+
+	shallow := m1["shallow"].(map[interface{}]interface{})
+	c.Assert(shallow["x"], Equals, 1)
+	c.Assert(shallow["y"], Equals, 2)
+
+	noclobber := m1["noclobber"].(map[interface{}]interface{})
+	c.Assert(noclobber["x"], Equals, 1)
+	c.Assert(noclobber["y"], Equals, 2)
+}
+
+func (s *S) TestMergeSequence(c *C) {
+	data := "center: &CENTER {x: 1, y: 2}\n" +
+		"left: &LEFT {x: 0, y: 2}\n" +
+		"far: &FAR {x: -10, y: 2}\n" +
+		"merge:\n  <<: [*CENTER, *LEFT, *FAR]\n  z: 0\n"
+	var m map[interface{}]interface{}
+	err := goyaml.Unmarshal([]byte(data), &m)
+	c.Assert(err, IsNil)
+
+	merge := m["merge"].(map[interface{}]interface{})
+	c.Assert(merge["x"], Equals, 1)
+	c.Assert(merge["y"], Equals, 2)
+	c.Assert(merge["z"], Equals, 0)
+}
+
+func (s *S) TestDecoderTypeError(c *C) {
+	var m struct {
+		V int8
+	}
+	dec := goyaml.NewDecoder(strings.NewReader("v: 128\n"))
+	err := dec.Decode(&m)
+	c.Assert(err, FitsTypeOf, &goyaml.TypeError{})
+	c.Assert(err.(*goyaml.TypeError).Errors, HasLen, 1)
+}
+
+func (s *S) TestDecoderKnownFieldsRejectsUnknownKey(c *C) {
+	var m struct {
+		Replicas int
+	}
+	dec := goyaml.NewDecoder(strings.NewReader("reeplicas: 3\n"))
+	dec.KnownFields(true)
+	err := dec.Decode(&m)
+	c.Assert(err, FitsTypeOf, &goyaml.TypeError{})
+	c.Assert(err.(*goyaml.TypeError).Errors, HasLen, 1)
+}
+
+func (s *S) TestDecodeNode(c *C) {
+	dec := goyaml.NewDecoder(strings.NewReader("v: &a 'hi'\nw: *a\n"))
+	var n goyaml.Node
+	err := dec.Decode(&n)
+	c.Assert(err, IsNil)
+	c.Assert(n.Kind, Equals, goyaml.DocumentNode)
+
+	var m map[string]string
+	err = n.Decode(&m)
+	c.Assert(err, IsNil)
+	c.Assert(m, DeepEquals, map[string]string{"v": "hi", "w": "hi"})
+}
+
+func (s *S) TestNodeStyle(c *C) {
+	dec := goyaml.NewDecoder(strings.NewReader("a: 'single'\nb: [1, 2]\n"))
+	var n goyaml.Node
+	err := dec.Decode(&n)
+	c.Assert(err, IsNil)
+
+	mapping := n.Children[0]
+	c.Assert(mapping.Kind, Equals, goyaml.MappingNode)
+	c.Assert(mapping.Children[1].Style, Equals, goyaml.SingleQuotedStyle)
+	c.Assert(mapping.Children[3].Style, Equals, goyaml.FlowStyle)
+}
+
+func (s *S) TestDecoderKnownFieldsRejectsDuplicateKey(c *C) {
+	var m map[string]int
+	dec := goyaml.NewDecoder(strings.NewReader("a: 1\na: 2\n"))
+	dec.KnownFields(true)
+	err := dec.Decode(&m)
+	c.Assert(err, FitsTypeOf, &goyaml.TypeError{})
+	c.Assert(err.(*goyaml.TypeError).Errors, HasLen, 1)
+}
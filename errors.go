@@ -0,0 +1,18 @@
+package goyaml
+
+import "strings"
+
+// A TypeError is returned by Decoder.Decode and Node.Decode when one or
+// more values in a YAML document could not be converted to the requested
+// Go type, but the document itself was otherwise well-formed. Every field
+// that did decode successfully is still populated; Errors lists one
+// message per problem, each prefixed with its "line:column" position in
+// the source. Unmarshal does not return a TypeError: it skips values it
+// cannot convert and reports no error for them.
+type TypeError struct {
+	Errors []string
+}
+
+func (e *TypeError) Error() string {
+	return "yaml: unmarshal errors:\n  " + strings.Join(e.Errors, "\n  ")
+}
@@ -0,0 +1,29 @@
+package goyaml
+
+import "reflect"
+
+// Decode converts the subtree rooted at n into v, exactly as Unmarshal
+// would for the equivalent document. It's the inverse of decoding into a
+// *Node: parse once into a Node tree for inspection or editing, then
+// Decode whichever parts of it are needed into concrete Go values.
+func (n *Node) Decode(v interface{}) (err error) {
+	defer handleErr(&err)
+	d := newDecoder()
+	if n.Kind == DocumentNode {
+		d.doc = n
+	} else {
+		// A Node handed out by Decoder.Decode is a document node and
+		// carries its own anchor map; anything else can't have aliases
+		// resolved against it, so fall back to an empty one.
+		d.doc = &Node{anchors: map[string]*Node{}}
+	}
+	out := reflect.ValueOf(v)
+	if out.Kind() == reflect.Ptr && !out.IsNil() {
+		out = out.Elem()
+	}
+	d.unmarshal(n, out)
+	if len(d.terrors) > 0 {
+		return &TypeError{Errors: d.terrors}
+	}
+	return nil
+}
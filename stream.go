@@ -0,0 +1,77 @@
+package goyaml
+
+import (
+	"io"
+	"reflect"
+	"runtime"
+)
+
+// A Decoder reads and decodes YAML documents from an input stream.
+//
+// Unlike Unmarshal, which requires the whole input up front, a Decoder
+// pulls bytes from its io.Reader on demand and yields one document per
+// call to Decode. This makes it suitable for large files and for
+// "---"-separated multi-document streams.
+type Decoder struct {
+	parser *parser
+	strict bool
+	closed bool
+}
+
+// NewDecoder returns a new decoder that reads from r. The Decoder holds
+// libyaml parser state that isn't freed by the Go garbage collector; call
+// Close once done with it. A finalizer also calls Close as a backstop,
+// but callers that stream many documents (e.g. a long-running service)
+// shouldn't rely on finalization timing.
+func NewDecoder(r io.Reader) *Decoder {
+	dec := &Decoder{parser: newParserFromReader(r)}
+	runtime.SetFinalizer(dec, (*Decoder).Close)
+	return dec
+}
+
+// Close releases the libyaml parser backing dec. It is safe to call more
+// than once, and Decode must not be called after Close.
+func (dec *Decoder) Close() {
+	if dec.closed {
+		return
+	}
+	dec.closed = true
+	dec.parser.destroy()
+}
+
+// KnownFields makes subsequent calls to Decode reject mapping keys that
+// don't resolve to a known struct field or tag, and mapping nodes with a
+// literal duplicate key, instead of silently ignoring them.
+func (dec *Decoder) KnownFields(b bool) {
+	dec.strict = b
+}
+
+// Decode reads the next YAML document from its input and stores it in the
+// value pointed to by v. It returns io.EOF when there are no more
+// documents in the stream.
+//
+// If v is a *Node, Decode populates it with the document's parsed tree
+// instead of converting into a Go value, preserving anchors, tags and
+// source Style so the document can be inspected or edited losslessly.
+func (dec *Decoder) Decode(v interface{}) (err error) {
+	defer handleErr(&err)
+	n, ok := dec.parser.nextDocument()
+	if !ok {
+		return io.EOF
+	}
+	if out, ok := v.(*Node); ok {
+		*out = *n
+		return nil
+	}
+	d := newDecoder()
+	d.strict = dec.strict
+	out := reflect.ValueOf(v)
+	if out.Kind() == reflect.Ptr && !out.IsNil() {
+		out = out.Elem()
+	}
+	d.unmarshal(n, out)
+	if len(d.terrors) > 0 {
+		return &TypeError{Errors: d.terrors}
+	}
+	return nil
+}